@@ -0,0 +1,48 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// DecodeConfig customizes how a Configer's Unmarshaler decodes a config
+// subtree into a Go struct.
+type DecodeConfig struct {
+	// WeaklyTypedInput mirrors mapstructure's flag of the same name: when
+	// true (the default) a value that arrived as a string - as most
+	// sources other than JSON hand every value to Configer - is converted
+	// into the target field's int/bool/float type. When false, source and
+	// target types must match exactly and a mismatch is a decode error
+	// instead of a silent coercion.
+	WeaklyTypedInput bool
+}
+
+// DecodeOption customizes a single Configer.Unmarshaler call.
+type DecodeOption func(*DecodeConfig)
+
+// WithStrictTypes disables the default weakly-typed decoding so that, for
+// example, a JSON document with `"port": "8080"` fails to decode into a
+// struct field of type int instead of silently coercing it.
+func WithStrictTypes() DecodeOption {
+	return func(c *DecodeConfig) {
+		c.WeaklyTypedInput = false
+	}
+}
+
+// NewDecodeConfig builds the default DecodeConfig and applies opt to it.
+func NewDecodeConfig(opt ...DecodeOption) *DecodeConfig {
+	cfg := &DecodeConfig{WeaklyTypedInput: true}
+	for _, o := range opt {
+		o(cfg)
+	}
+	return cfg
+}
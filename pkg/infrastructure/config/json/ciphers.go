@@ -0,0 +1,202 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"filippo.io/age"
+)
+
+// Default env vars consulted by the ciphers registered for the
+// "AES256-GCM" and "age" names out of the box. Applications that want a
+// different source - a KMS call, a secrets manager - register their own
+// Cipher under the same name with RegisterCipher to override these.
+const (
+	envAESKey      = "BEEGO_CONFIG_AES_KEY"
+	envAgeIdentity = "BEEGO_CONFIG_AGE_IDENTITY"
+)
+
+func init() {
+	RegisterCipher("AES256-GCM", NewAESGCMCipherFromEnv(envAESKey))
+	RegisterCipher("age", &lazyAgeCipher{envVar: envAgeIdentity})
+}
+
+// KeyFunc supplies key material on demand - from an env var, a KMS call,
+// whatever the caller needs - rather than baking a static key into the
+// process at registration time.
+type KeyFunc func() ([]byte, error)
+
+// AESGCMCipher implements Cipher and EncryptingCipher using AES-256 in GCM
+// mode. keyFn is consulted on every call so a rotated key takes effect
+// without re-registering the cipher.
+type AESGCMCipher struct {
+	keyFn KeyFunc
+}
+
+// NewAESGCMCipher builds a Cipher backed by a fixed 32-byte key.
+func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("AES-256-GCM requires a 32-byte key, got %d", len(key))
+	}
+	return &AESGCMCipher{keyFn: func() ([]byte, error) { return key, nil }}, nil
+}
+
+// NewAESGCMCipherFromKeyFunc builds a Cipher that asks keyFn for the key on
+// every Decrypt/Encrypt call, e.g. to fetch it from a KMS.
+func NewAESGCMCipherFromKeyFunc(keyFn KeyFunc) *AESGCMCipher {
+	return &AESGCMCipher{keyFn: keyFn}
+}
+
+// NewAESGCMCipherFromEnv builds a Cipher whose key is the base64-decoded
+// contents of the named environment variable, read fresh on every call.
+func NewAESGCMCipherFromEnv(envVar string) *AESGCMCipher {
+	return &AESGCMCipher{keyFn: func() ([]byte, error) {
+		raw := os.Getenv(envVar)
+		if raw == "" {
+			return nil, fmt.Errorf("env var %s is not set", envVar)
+		}
+		key, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("env var %s is not valid base64: %w", envVar, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("AES-256-GCM requires a 32-byte key, got %d", len(key))
+		}
+		return key, nil
+	}}
+}
+
+func (a *AESGCMCipher) gcm() (cipher.AEAD, error) {
+	key, err := a.keyFn()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Decrypt implements Cipher.
+func (a *AESGCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := a.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext shorter than nonce size")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// Encrypt implements EncryptingCipher.
+func (a *AESGCMCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := a.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// AgeCipher implements Cipher and EncryptingCipher using age
+// (https://age-encryption.org) X25519 identities: secrets are encrypted to
+// a recipient's public key and can only be decrypted by whoever holds the
+// matching AGE-SECRET-KEY-... identity.
+type AgeCipher struct {
+	identity  age.Identity
+	recipient age.Recipient
+}
+
+// NewAgeCipher builds a Cipher from an AGE-SECRET-KEY-... identity string.
+// The matching recipient is derived from it, so the same value both
+// decrypts existing secrets and re-encrypts new ones on save.
+func NewAgeCipher(identityStr string) (*AgeCipher, error) {
+	id, err := age.ParseX25519Identity(identityStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing age identity: %w", err)
+	}
+	return &AgeCipher{identity: id, recipient: id.Recipient()}, nil
+}
+
+// Decrypt implements Cipher.
+func (a *AgeCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.identity)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// Encrypt implements EncryptingCipher.
+func (a *AgeCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, a.recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lazyAgeCipher defers parsing the age identity until first use, so a
+// process that never touches age-encrypted config doesn't fail just
+// because envVar isn't set.
+type lazyAgeCipher struct {
+	envVar string
+}
+
+func (l *lazyAgeCipher) resolve() (*AgeCipher, error) {
+	v := os.Getenv(l.envVar)
+	if v == "" {
+		return nil, fmt.Errorf("env var %s is not set", l.envVar)
+	}
+	return NewAgeCipher(v)
+}
+
+func (l *lazyAgeCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	c, err := l.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return c.Decrypt(ciphertext)
+}
+
+func (l *lazyAgeCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	c, err := l.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return c.Encrypt(plaintext)
+}
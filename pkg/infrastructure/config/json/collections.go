@@ -0,0 +1,137 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// IntSlice returns the []int value for a key addressing a JSON array,
+// e.g. a list of worker ports.
+func (c *JSONConfigContainer) IntSlice(ctx context.Context, key string) ([]int, error) {
+	arr, err := c.rawSlice(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int, 0, len(arr))
+	for i, item := range arr {
+		switch v := item.(type) {
+		case float64:
+			out = append(out, int(v))
+		case string:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: not an int: %v", key, i, v)
+			}
+			out = append(out, n)
+		default:
+			return nil, fmt.Errorf("%s[%d]: not an int: %v", key, i, v)
+		}
+	}
+	return out, nil
+}
+
+// StringSlice returns the []string value for a key addressing a JSON
+// array. Every element must be a JSON string; enc:-prefixed elements are
+// decrypted the same way String decrypts them, and a non-string element
+// (number, bool, object, ...) is an error.
+func (c *JSONConfigContainer) StringSlice(ctx context.Context, key string) ([]string, error) {
+	arr, err := c.rawSlice(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(arr))
+	for i, item := range arr {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: not a string: %v", key, i, item)
+		}
+		decrypted, err := decryptValue(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		out = append(out, decrypted)
+	}
+	return out, nil
+}
+
+// Float64Slice returns the []float64 value for a key addressing a JSON
+// array.
+func (c *JSONConfigContainer) Float64Slice(ctx context.Context, key string) ([]float64, error) {
+	arr, err := c.rawSlice(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, 0, len(arr))
+	for i, item := range arr {
+		switch v := item.(type) {
+		case float64:
+			out = append(out, v)
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: not a float64: %v", key, i, v)
+			}
+			out = append(out, f)
+		default:
+			return nil, fmt.Errorf("%s[%d]: not a float64: %v", key, i, v)
+		}
+	}
+	return out, nil
+}
+
+// rawSlice returns the []interface{} backing a JSON array at key, as
+// produced by encoding/json.
+func (c *JSONConfigContainer) rawSlice(key string) ([]interface{}, error) {
+	val := c.getData(key)
+	if val == nil {
+		return nil, fmt.Errorf("not exist key: %q", key)
+	}
+	arr, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("key %q is not an array", key)
+	}
+	return arr, nil
+}
+
+// MapStringString returns the object at key as a flattened map of strings,
+// the same way GetSection flattens a section.
+func (c *JSONConfigContainer) MapStringString(ctx context.Context, key string) (map[string]string, error) {
+	val := c.getData(key)
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("key %q is not an object", key)
+	}
+	out := make(map[string]string)
+	flatten("", m, out)
+	return out, nil
+}
+
+// MapStringInterface returns the raw object at key, decrypting any
+// enc:-prefixed string leaves along the way.
+func (c *JSONConfigContainer) MapStringInterface(ctx context.Context, key string) (map[string]interface{}, error) {
+	val := c.getData(key)
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("key %q is not an object", key)
+	}
+	decrypted, err := decryptAny(m)
+	if err != nil {
+		return nil, err
+	}
+	return decrypted.(map[string]interface{}), nil
+}
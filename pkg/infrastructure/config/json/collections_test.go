@@ -0,0 +1,174 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGetSectionFlattensNestedJSON(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"x","port":5432}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cfg.GetSection(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"host": "x", "port": "5432"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestGetSectionLegacyModeRestoresOriginalUncheckedAssertion(t *testing.T) {
+	js := &JSONConfig{LegacyMode: true}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"x","port":5432}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A real JSON object always decodes into map[string]interface{}, never
+	// map[string]string, so LegacyMode's restored `v.(map[string]string)`
+	// assertion panics on it just like the pre-chunk0-6 code did - that's
+	// the documented, intentionally-preserved legacy behavior, not a
+	// regression.
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected LegacyMode's unchecked type assertion to panic on a real JSON object")
+		}
+	}()
+	_, _ = cfg.GetSection(context.Background(), "db")
+}
+
+func TestStringsLegacyModeSplitsOnSemicolon(t *testing.T) {
+	js := &JSONConfig{LegacyMode: true}
+	cfg, err := js.ParseData([]byte(`{"tags":"a;b;c"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cfg.Strings(context.Background(), "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStringsNonLegacyModeReadsJSONArray(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"tags":["a","b","c"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cfg.Strings(context.Background(), "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIntSlice(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"ports":[80,443,8080]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	got, err := c.IntSlice(context.Background(), "ports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{80, 443, 8080}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFloat64Slice(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"weights":[0.1,0.2,0.3]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	got, err := c.Float64Slice(context.Background(), "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0.1, 0.2, 0.3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMapStringString(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"x","port":5432}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	got, err := c.MapStringString(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"host": "x", "port": "5432"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMapStringInterfaceDecryptsSecretLeaves(t *testing.T) {
+	cipherName := "test-aes-gcm-collections"
+	c := registerTestAESCipher(t, cipherName)
+	ciphertext, err := c.Encrypt([]byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := formatEncrypted(cipherName, ciphertext)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"x","password":"` + enc + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := cfg.(*JSONConfigContainer)
+
+	got, err := container.MapStringInterface(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["host"] != "x" {
+		t.Fatalf("expected host %q, got %v", "x", got["host"])
+	}
+	if got["password"] != "s3cret" {
+		t.Fatalf("expected decrypted password, got %v", got["password"])
+	}
+}
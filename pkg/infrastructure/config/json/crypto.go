@@ -0,0 +1,222 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// encPrefix marks a string value as an encrypted secret: "enc:<cipher
+// name>:<base64 ciphertext>", e.g. "enc:AES256-GCM:6gF3...".
+const encPrefix = "enc:"
+
+// Cipher decrypts values stored under the "enc:<name>:<base64>" convention.
+// Implementations are registered with RegisterCipher under the name that
+// appears in that prefix.
+type Cipher interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// EncryptingCipher is a Cipher that can also re-encrypt a value. Only
+// ciphers implementing it support SaveConfigFile writing back a secret
+// whose plaintext changed since it was loaded; a Decrypt-only Cipher can
+// read existing secrets but SaveConfigFile refuses to persist a new value
+// for one of its keys.
+type EncryptingCipher interface {
+	Cipher
+	Encrypt(plaintext []byte) ([]byte, error)
+}
+
+var (
+	ciphersMu sync.RWMutex
+	ciphers   = map[string]Cipher{}
+)
+
+// RegisterCipher makes c available for decrypting (and, if it implements
+// EncryptingCipher, re-encrypting) values tagged "enc:<name>:...". Calling
+// it again with the same name replaces the previous registration.
+func RegisterCipher(name string, c Cipher) {
+	ciphersMu.Lock()
+	defer ciphersMu.Unlock()
+	ciphers[name] = c
+}
+
+func getCipher(name string) (Cipher, bool) {
+	ciphersMu.RLock()
+	defer ciphersMu.RUnlock()
+	c, ok := ciphers[name]
+	return c, ok
+}
+
+// parseEncrypted splits an "enc:<name>:<base64>" value into its cipher name
+// and raw ciphertext. ok is false for any value that isn't in that form,
+// which callers treat as "not a secret, use as-is".
+func parseEncrypted(val string) (name string, ciphertext []byte, ok bool) {
+	if !strings.HasPrefix(val, encPrefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimPrefix(val, encPrefix)
+	idx := strings.Index(rest, ":")
+	if idx < 0 {
+		return "", nil, false
+	}
+	raw, err := base64.StdEncoding.DecodeString(rest[idx+1:])
+	if err != nil {
+		return "", nil, false
+	}
+	return rest[:idx], raw, true
+}
+
+func formatEncrypted(name string, ciphertext []byte) string {
+	return encPrefix + name + ":" + base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+// decryptValue transparently decrypts val if it carries the enc: prefix
+// and a cipher is registered under its name; any other string is returned
+// unchanged.
+func decryptValue(val string) (string, error) {
+	name, ciphertext, ok := parseEncrypted(val)
+	if !ok {
+		return val, nil
+	}
+	c, ok := getCipher(name)
+	if !ok {
+		return "", fmt.Errorf("no cipher registered for %q", name)
+	}
+	plain, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// decryptAny walks v, decrypting every enc:-prefixed string leaf. It is
+// used by DIY and Unmarshaler so callers working with a subtree never see
+// ciphertext.
+func decryptAny(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return decryptValue(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, vv := range val {
+			nv, err := decryptAny(vv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = nv
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			nv, err := decryptAny(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = nv
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// detectEncryptedKeys flattens data and records the cipher name for every
+// enc:-prefixed leaf, keyed by its dotted path. The container keeps this
+// around after load so SaveConfigFile knows which keys must be
+// re-encrypted rather than written back as plaintext.
+func detectEncryptedKeys(data map[string]interface{}) map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, vv := range val {
+				p := k
+				if prefix != "" {
+					p = prefix + "." + k
+				}
+				walk(p, vv)
+			}
+		case []interface{}:
+			for i, item := range val {
+				walk(fmt.Sprintf("%s.%d", prefix, i), item)
+			}
+		case string:
+			if name, _, ok := parseEncrypted(val); ok {
+				out[prefix] = name
+			}
+		}
+	}
+	for k, v := range data {
+		walk(k, v)
+	}
+	return out
+}
+
+// dataForSave returns a deep copy of c.data with every key in
+// c.encryptedKeys re-encrypted if its value was changed to plaintext since
+// load (via Set/SetByPath), and left untouched if it's still the original
+// ciphertext - so a round-trip Parse/SaveConfigFile never leaks a secret
+// that was never modified.
+func (c *JSONConfigContainer) dataForSave() (map[string]interface{}, error) {
+	c.RLock()
+	raw, err := json.Marshal(c.data)
+	encryptedKeys := c.encryptedKeys
+	c.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	for path, name := range encryptedKeys {
+		segments := parsePath(path)
+		cur, err := getAtPath(data, segments)
+		if err != nil {
+			continue // the key was removed since load; nothing to save
+		}
+		str, ok := cur.(string)
+		if !ok {
+			continue
+		}
+		if _, _, already := parseEncrypted(str); already {
+			continue // untouched secret: keep the original ciphertext byte-for-byte
+		}
+
+		cph, ok := getCipher(name)
+		enc, ok2 := cph.(EncryptingCipher)
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("cannot save %s: no encrypting cipher registered for %q", path, name)
+		}
+		ciphertext, err := enc.Encrypt([]byte(str))
+		if err != nil {
+			return nil, fmt.Errorf("re-encrypting %s: %w", path, err)
+		}
+		if err := setSegment(&data, nil, segments, formatEncrypted(name, ciphertext)); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"testing"
+)
+
+func registerTestAESCipher(t *testing.T, name string) *AESGCMCipher {
+	t.Helper()
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	c, err := NewAESGCMCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterCipher(name, c)
+	return c
+}
+
+func TestStringTransparentlyDecryptsEncValue(t *testing.T) {
+	cipherName := "test-aes-gcm"
+	c := registerTestAESCipher(t, cipherName)
+
+	ciphertext, err := c.Encrypt([]byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := formatEncrypted(cipherName, ciphertext)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"password":"` + enc + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cfg.String(context.Background(), "db::password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "s3cret" {
+		t.Fatalf("expected decrypted value \"s3cret\", got %q", got)
+	}
+}
+
+func TestDataForSaveReencryptsChangedSecretAndLeavesUntouchedOneAlone(t *testing.T) {
+	cipherName := "test-aes-gcm-save"
+	c := registerTestAESCipher(t, cipherName)
+
+	ciphertext, err := c.Encrypt([]byte("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := formatEncrypted(cipherName, ciphertext)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"password":"` + enc + `"},"cache":{"password":"` + enc + `"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := cfg.(*JSONConfigContainer)
+
+	if err := container.SetByPath(context.Background(), "cache.password", "rotated"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := container.dataForSave()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	untouched := data["db"].(map[string]interface{})["password"].(string)
+	if untouched != enc {
+		t.Fatalf("expected untouched secret to keep its original ciphertext, got %q", untouched)
+	}
+
+	rotated := data["cache"].(map[string]interface{})["password"].(string)
+	name, ciphertextOut, ok := parseEncrypted(rotated)
+	if !ok || name != cipherName {
+		t.Fatalf("expected rotated secret to be re-encrypted under %q, got %q", cipherName, rotated)
+	}
+	plain, err := c.Decrypt(ciphertextOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "rotated" {
+		t.Fatalf("expected rotated plaintext to round-trip, got %q", plain)
+	}
+}
@@ -28,26 +28,45 @@ import (
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/astaxie/beego/pkg/infrastructure/config"
-	"github.com/astaxie/beego/pkg/infrastructure/logs"
 )
 
 // JSONConfig is a json config parser and implements Config interface.
 type JSONConfig struct {
+	// LegacyMode restores the pre-array-aware behavior of GetSection (an
+	// unchecked `map[string]string` assertion) and Strings (splitting a
+	// single string on ";") for callers that relied on it. It defaults to
+	// false, meaning containers handle real JSON objects and arrays
+	// correctly out of the box.
+	LegacyMode bool
+
+	// schemas holds the schemas registered via RegisterSchema, shared with
+	// every container this JSONConfig parses.
+	schemas *schemaRegistry
 }
 
 // Parse returns a ConfigContainer with parsed json config map.
 func (js *JSONConfig) Parse(filename string) (config.Configer, error) {
-	file, err := os.Open(filename)
+	content, err := readFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	content, err := ioutil.ReadAll(file)
+
+	x, err := js.ParseData(content)
 	if err != nil {
 		return nil, err
 	}
+	x.(*JSONConfigContainer).filename = filename
 
-	return js.ParseData(content)
+	return x, nil
+}
+
+func readFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
 }
 
 // ParseData returns a ConfigContainer with json string
@@ -65,36 +84,160 @@ func (js *JSONConfig) ParseData(data []byte) (config.Configer, error) {
 		x.data["rootArray"] = wrappingArray
 	}
 
-	x.data = config.ExpandValueEnvForMap(x.data)
+	x.data = flattenedEnv(x.data)
+	x.schemas = js.schemas
+	x.encryptedKeys = detectEncryptedKeys(x.data)
+	x.legacyMode = js.LegacyMode
 
 	return x, nil
 }
 
+func flattenedEnv(data map[string]interface{}) map[string]interface{} {
+	return config.ExpandValueEnvForMap(data)
+}
+
 // JSONConfigContainer is a config which represents the json configuration.
 // Only when get value, support key as section:name type.
 type JSONConfigContainer struct {
 	data map[string]interface{}
 	sync.RWMutex
+
+	// filename is the file Parse loaded data from, if any. It is used by
+	// OnChange to start a local file watcher.
+	filename string
+
+	// watch lazily holds the subscription/reload state for this container;
+	// it stays nil until OnChange or WatchRemote is first called.
+	watch *watchState
+
+	// schemas is shared with the JSONConfig that parsed this container; it
+	// is nil unless RegisterSchema was called.
+	schemas *schemaRegistry
+
+	// provenance maps a dotted key path to the file it was last set from.
+	// It is only populated when the container was built via ParseFiles or
+	// ParseWithProfile.
+	provenance map[string]string
+
+	// encryptedKeys maps a dotted key path to the cipher name it was
+	// encrypted with at load time, so SaveConfigFile knows which keys need
+	// re-encrypting instead of being written back as plaintext.
+	encryptedKeys map[string]string
+
+	// legacyMode mirrors the JSONConfig.LegacyMode that produced this
+	// container; see GetSection and Strings.
+	legacyMode bool
+
+	// subPrefix is the dotted path from the root document down to this
+	// container's data, set by Sub. It is empty for a root container. A
+	// watched sub-container uses it to re-extract its own slice of a
+	// reloaded document instead of replacing its data with the whole thing.
+	subPrefix string
 }
 
+// Source returns the file that contributed the current value at key, where
+// key is addressed the same way as GetByPath (dotted, e.g. "servers.0.port").
+// Provenance is recorded for leaf values, arrays and everything under an
+// array; an object that was deep-merged (e.g. "db") has no entry of its own.
+// It returns "" for such an object, for a key that did not exist at load
+// time, and for every key of a container not built via ParseFiles or
+// ParseWithProfile. Set, SetByPath, DeleteByPath and reloads do not update
+// it.
+func (c *JSONConfigContainer) Source(key string) string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.provenance[key]
+}
+
+// Unmarshaler decodes the subtree at prefix into obj. When a schema was
+// registered for the full root-relative path (c.subPrefix joined with
+// prefix - just prefix for a root container) via JSONConfig.RegisterSchema,
+// the subtree is validated against it first, failing fast with a
+// path-qualified error instead of leaving obj partially populated with zero
+// values. Struct tags `default:"..."`, `required:"true"` and
+// `validate:"min=1,max=65535"` on obj are then applied before the
+// mapstructure decode.
 func (c *JSONConfigContainer) Unmarshaler(ctx context.Context, prefix string, obj interface{}, opt ...config.DecodeOption) error {
 	sub, err := c.sub(ctx, prefix)
 	if err != nil {
 		return err
 	}
-	return mapstructure.Decode(sub, obj)
+	decrypted, err := decryptAny(sub)
+	if err != nil {
+		return err
+	}
+	sub = decrypted.(map[string]interface{})
+
+	c.RLock()
+	schemaPrefix := joinPrefix(c.subPrefix, prefix)
+	c.RUnlock()
+
+	if err := c.schemas.validate(schemaPrefix, sub); err != nil {
+		return err
+	}
+	if err := applyStructTags(obj, sub, prefix); err != nil {
+		return err
+	}
+
+	decodeCfg := config.NewDecodeConfig(opt...)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: decodeCfg.WeaklyTypedInput,
+		Result:           obj,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(sub)
 }
 
+// Sub returns the subtree at key as its own Configer, carrying over the
+// parent's schema registry, LegacyMode setting and filename so that, e.g.,
+// sub.Unmarshaler still validates against a schema registered for the full
+// path and sub.GetSection/sub.Strings still honor LegacyMode. enc:-prefixed
+// values under the subtree are re-scanned into the sub-container's own
+// encryptedKeys, since a key recorded against the parent's root-relative
+// path wouldn't match anything relative to the new root. The sub-container
+// does not inherit the parent's watch subscriptions; OnChange/WatchRemote
+// called on it lazily start their own watcher against the same filename,
+// and reload scopes itself back down to this subtree via subPrefix.
 func (c *JSONConfigContainer) Sub(ctx context.Context, key string) (config.Configer, error) {
+	c.RLock()
+	filename := c.filename
+	schemas := c.schemas
+	legacyMode := c.legacyMode
+	prefix := c.subPrefix
+	c.RUnlock()
+
 	sub, err := c.sub(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 	return &JSONConfigContainer{
-		data: sub,
+		data:          sub,
+		filename:      filename,
+		schemas:       schemas,
+		legacyMode:    legacyMode,
+		encryptedKeys: detectEncryptedKeys(sub),
+		subPrefix:     joinPrefix(prefix, key),
 	}, nil
 }
 
+// joinPrefix dot-joins a container's subPrefix (the root-relative path Sub
+// already descended) with the prefix passed to a single call - used to
+// build the root-relative path a schema would have been registered under,
+// and to extend subPrefix one level further on a nested Sub. An empty
+// operand is dropped rather than leaving a stray leading/trailing ".".
+func joinPrefix(base, key string) string {
+	switch {
+	case base == "":
+		return key
+	case key == "":
+		return base
+	default:
+		return base + pathSeparator + key
+	}
+}
+
 func (c *JSONConfigContainer) sub(ctx context.Context, key string) (map[string]interface{}, error) {
 	if key == "" {
 		return c.data, nil
@@ -111,10 +254,6 @@ func (c *JSONConfigContainer) sub(ctx context.Context, key string) (map[string]i
 	return res, nil
 }
 
-func (c *JSONConfigContainer) OnChange(ctx context.Context, key string, fn func(value string)) {
-	logs.Warn("unsupported operation")
-}
-
 // Bool returns the boolean value for a given key.
 func (c *JSONConfigContainer) Bool(ctx context.Context, key string) (bool, error) {
 	val := c.getData(key)
@@ -198,12 +337,14 @@ func (c *JSONConfigContainer) DefaultFloat(ctx context.Context, key string, defa
 	return defaultVal
 }
 
-// String returns the string value for a given key.
+// String returns the string value for a given key. A value stored under
+// the "enc:<cipher>:<base64>" convention (see RegisterCipher) is
+// transparently decrypted before it is returned.
 func (c *JSONConfigContainer) String(ctx context.Context, key string) (string, error) {
 	val := c.getData(key)
 	if val != nil {
 		if v, ok := val.(string); ok {
-			return v, nil
+			return decryptValue(v)
 		}
 	}
 	return "", nil
@@ -219,13 +360,20 @@ func (c *JSONConfigContainer) DefaultString(ctx context.Context, key string, def
 	return defaultVal
 }
 
-// Strings returns the []string value for a given key.
+// Strings returns the []string value for a given key. By default this
+// reads a JSON array of strings via StringSlice, so a non-string element is
+// an error; with LegacyMode set on
+// the JSONConfig that produced this container, it instead keeps the
+// original behavior of splitting a single string value on ";".
 func (c *JSONConfigContainer) Strings(ctx context.Context, key string) ([]string, error) {
-	stringVal, err := c.String(nil, key)
-	if stringVal == "" || err != nil {
-		return nil, err
+	if c.legacyMode {
+		stringVal, err := c.String(ctx, key)
+		if stringVal == "" || err != nil {
+			return nil, err
+		}
+		return strings.Split(stringVal, ";"), nil
 	}
-	return strings.Split(stringVal, ";"), nil
+	return c.StringSlice(ctx, key)
 }
 
 // DefaultStrings returns the []string value for a given key.
@@ -237,23 +385,52 @@ func (c *JSONConfigContainer) DefaultStrings(ctx context.Context, key string, de
 	return defaultVal
 }
 
-// GetSection returns map for the given section
+// GetSection returns a flattened string view of the given section: nested
+// objects are addressed with "."-joined keys and scalar leaves are
+// stringified, e.g. {"db":{"host":"x","port":5432}} yields
+// {"host":"x","port":"5432"} for section "db". With LegacyMode set on the
+// JSONConfig that produced this container, it instead keeps the original
+// `v.(map[string]string)` assertion, which only worked for documents that
+// happened to decode into that exact shape.
 func (c *JSONConfigContainer) GetSection(ctx context.Context, section string) (map[string]string, error) {
-	if v, ok := c.data[section]; ok {
+	c.RLock()
+	v, ok := c.data[section]
+	c.RUnlock()
+	if !ok {
+		return nil, errors.New("nonexist section " + section)
+	}
+
+	if c.legacyMode {
 		return v.(map[string]string), nil
 	}
-	return nil, errors.New("nonexist section " + section)
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("section %q is not an object", section)
+	}
+	out := make(map[string]string)
+	flatten("", m, out)
+	return out, nil
 }
 
-// SaveConfigFile save the config into file
+// SaveConfigFile save the config into file. Any key that was loaded from
+// an "enc:<cipher>:<base64>" value is re-encrypted before being written
+// back if its plaintext changed since load (via Set/SetByPath), and left
+// as the original ciphertext otherwise - so a round-trip never leaks a
+// secret that was never touched.
 func (c *JSONConfigContainer) SaveConfigFile(ctx context.Context, filename string) (err error) {
+	data, err := c.dataForSave()
+	if err != nil {
+		return err
+	}
+
 	// Write configuration file by filename.
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	b, err := json.MarshalIndent(c.data, "", "  ")
+	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -261,19 +438,31 @@ func (c *JSONConfigContainer) SaveConfigFile(ctx context.Context, filename strin
 	return err
 }
 
-// Set writes a new value for key.
+// Set writes a new value for key as a top-level entry, with key stored
+// verbatim: neither a "section::name" key nor a key containing "." - an IP,
+// a hostname, a version string - is split into nested levels. Use
+// SetByPath explicitly when key is meant as a gjson/sjson-style path.
 func (c *JSONConfigContainer) Set(ctx context.Context, key, val string) error {
 	c.Lock()
 	defer c.Unlock()
+	if c.data == nil {
+		// A watched Sub() container whose subPrefix stopped resolving after
+		// a reload (the upstream key was renamed or removed) lands here with
+		// a nil data map rather than panicking on a now-pointless write.
+		c.data = make(map[string]interface{})
+	}
 	c.data[key] = val
 	return nil
 }
 
-// DIY returns the raw value by a given key.
+// DIY returns the raw value by a given key, using the original flat
+// "section::name" (or plain key) lookup. Use GetByPath explicitly when key
+// is meant as a gjson/sjson-style path (e.g. "servers.0.port") rather than
+// a literal key that happens to contain a dot.
 func (c *JSONConfigContainer) DIY(ctx context.Context, key string) (v interface{}, err error) {
 	val := c.getData(key)
 	if val != nil {
-		return val, nil
+		return decryptAny(val)
 	}
 	return nil, errors.New("not exist key")
 }
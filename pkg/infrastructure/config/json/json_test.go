@@ -0,0 +1,48 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetOnSubContainerAfterSubPrefixStopsResolvingDoesNotPanic(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"a"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	sub, err := c.Sub(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subContainer := sub.(*JSONConfigContainer)
+
+	ws := subContainer.ensureWatchState()
+	// Simulate a reload whose new document no longer has a "db" key at all -
+	// subtreeAt returns nil and the sub-container's data would be nil if
+	// Set/SetByPath didn't guard against it.
+	reloadFromBytes(subContainer, ws, []byte(`{"other":"x"}`))
+
+	if err := subContainer.Set(context.Background(), "host", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := subContainer.SetByPath(context.Background(), "host", "c"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/astaxie/beego/pkg/infrastructure/config"
+)
+
+// include/$include are the directive keys ParseFiles looks for inside a
+// JSON document to pull in other files before merging.
+const (
+	includeKey       = "include"
+	includeKeyDollar = "$include"
+)
+
+// ParseFiles loads each of filenames in turn and deep-merges them into a
+// single container, with later files overriding earlier ones key-by-key.
+// Maps are merged recursively; arrays, like any other scalar, are replaced
+// wholesale by whichever file sets them last. Each file may itself pull in
+// further files via an "include" (or "$include") key holding a path or list
+// of paths, resolved relative to the including file and routed through the
+// adapters registered with config.Register so an include can name a JSON,
+// YAML or INI file interchangeably.
+func (js *JSONConfig) ParseFiles(filenames ...string) (config.Configer, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("ParseFiles requires at least one filename")
+	}
+
+	merged := make(map[string]interface{})
+	prov := make(map[string]string)
+	for _, filename := range filenames {
+		data, fileProv, err := js.loadFileWithIncludes(filename, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		mergeMapInto(merged, data, "", filename, prov, fileProv)
+	}
+
+	mergedData := flattenedEnv(merged)
+	x := &JSONConfigContainer{
+		data:          mergedData,
+		provenance:    prov,
+		schemas:       js.schemas,
+		encryptedKeys: detectEncryptedKeys(mergedData),
+		legacyMode:    js.LegacyMode,
+	}
+	if len(filenames) == 1 {
+		x.filename = filenames[0]
+	}
+	return x, nil
+}
+
+// ParseWithProfile loads filename, then overlays filename.<profile><ext> on
+// top of it when that overlay exists (app.json + app.dev.json), mirroring
+// the dev/staging/prod layering convention of CONFIG_PATH-driven
+// deployments. It is a no-op wrapper around ParseFiles when the overlay
+// file is absent.
+func (js *JSONConfig) ParseWithProfile(filename, profile string) (config.Configer, error) {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	overlay := fmt.Sprintf("%s.%s%s", base, profile, ext)
+
+	if _, err := os.Stat(overlay); err != nil {
+		return js.ParseFiles(filename)
+	}
+	return js.ParseFiles(filename, overlay)
+}
+
+// loadFileWithIncludes parses filename and recursively resolves its
+// include/$include directives, returning the merged document together with
+// per-key provenance. seen guards against an include cycle: it tracks only
+// the files on the current root-to-leaf include path, not every file visited
+// anywhere in the tree, so two unrelated branches that both legitimately
+// include the same shared file (app.dev.json and app.prod.json both pulling
+// in common.json) don't trip a false "circular include" - the entry is
+// removed again once this branch finishes, via the deferred delete below.
+func (js *JSONConfig) loadFileWithIncludes(filename string, seen map[string]bool) (map[string]interface{}, map[string]string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		abs = filename
+	}
+	if seen[abs] {
+		return nil, nil, fmt.Errorf("circular include detected at %s", filename)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	content, err := readFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	includes := extractIncludes(doc)
+	merged := make(map[string]interface{})
+	prov := make(map[string]string)
+	dir := filepath.Dir(filename)
+
+	for _, inc := range includes {
+		incPath := inc
+		if !filepath.IsAbs(incPath) {
+			incPath = filepath.Join(dir, incPath)
+		}
+
+		var incData map[string]interface{}
+		var incProv map[string]string
+		switch ext := strings.TrimPrefix(filepath.Ext(incPath), "."); ext {
+		case "", "json":
+			incData, incProv, err = js.loadFileWithIncludes(incPath, seen)
+		default:
+			incData, err = loadGenericFile(ext, incPath)
+			incProv = sourceAll(incData, incPath)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("include %q from %s: %w", inc, filename, err)
+		}
+		mergeMapInto(merged, incData, "", incPath, prov, incProv)
+	}
+
+	mergeMapInto(merged, doc, "", filename, prov, nil)
+	return merged, prov, nil
+}
+
+// extractIncludes removes the include/$include directive from doc, if
+// present, and returns the list of paths it named.
+func extractIncludes(doc map[string]interface{}) []string {
+	var out []string
+	for _, key := range []string{includeKeyDollar, includeKey} {
+		raw, ok := doc[key]
+		if !ok {
+			continue
+		}
+		delete(doc, key)
+		switch v := raw.(type) {
+		case string:
+			out = append(out, v)
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// loadGenericFile loads a non-JSON include through the adapter registered
+// for ext under config.Register, decoding it into a plain map so it can be
+// deep-merged the same way as a JSON include.
+func loadGenericFile(ext, path string) (map[string]interface{}, error) {
+	cfg, err := config.NewConfig(ext, path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{})
+	if err := cfg.Unmarshaler(context.Background(), "", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sourceAll flattens data and attributes every leaf path to source; it is
+// used to give a non-JSON include per-key provenance equivalent to what
+// loadFileWithIncludes tracks natively for JSON includes.
+func sourceAll(data map[string]interface{}, source string) map[string]string {
+	out := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			out[prefix] = source
+			return
+		}
+		for k, vv := range m {
+			p := k
+			if prefix != "" {
+				p = prefix + "." + k
+			}
+			walk(p, vv)
+		}
+	}
+	for k, v := range data {
+		walk(k, v)
+	}
+	return out
+}
+
+// mergeMapInto deep-merges src into dst: nested objects are merged
+// recursively, everything else (scalars and arrays alike) is replaced
+// wholesale. defaultSource attributes provenance for keys that aren't
+// already present in srcProv (srcProv is nil for a plain single-file
+// merge, where every key naturally comes from defaultSource). An array is
+// replaced wholesale, but recordProvenance still walks into it so
+// Source("servers.0.port") resolves the same way GetByPath addresses it,
+// not just Source("servers").
+func mergeMapInto(dst, src map[string]interface{}, prefix, defaultSource string, prov, srcProv map[string]string) {
+	for k, v := range src {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		if srcMap, ok := v.(map[string]interface{}); ok {
+			dstMap, ok2 := dst[k].(map[string]interface{})
+			if !ok2 {
+				// The old value (scalar or array) is gone; drop its provenance.
+				for p := range prov {
+					if p == path || strings.HasPrefix(p, path+".") {
+						delete(prov, p)
+					}
+				}
+				dstMap = make(map[string]interface{})
+				dst[k] = dstMap
+			}
+			mergeMapInto(dstMap, srcMap, path, defaultSource, prov, srcProv)
+			continue
+		}
+
+		// Drop entries under the replaced value, e.g. "servers.1.port" when a
+		// shorter array or a scalar replaces the old one.
+		for p := range prov {
+			if strings.HasPrefix(p, path+".") {
+				delete(prov, p)
+			}
+		}
+		dst[k] = v
+		recordProvenance(v, path, defaultSource, prov, srcProv)
+	}
+}
+
+// recordProvenance attributes path - and, for an array or an object nested
+// inside one, every dotted path underneath it - to whichever source
+// srcProv already recorded for that exact path, falling back to
+// defaultSource. It mirrors flatten()'s "." and ".N" addressing so
+// Source(key) always agrees with GetByPath(key) on what a path means.
+func recordProvenance(v interface{}, path, defaultSource string, prov, srcProv map[string]string) {
+	source := defaultSource
+	if srcProv != nil {
+		if s, ok := srcProv[path]; ok {
+			source = s
+		}
+	}
+	prov[path] = source
+
+	switch val := v.(type) {
+	case []interface{}:
+		for i, item := range val {
+			recordProvenance(item, fmt.Sprintf("%s.%d", path, i), defaultSource, prov, srcProv)
+		}
+	case map[string]interface{}:
+		for k, vv := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			recordProvenance(vv, p, defaultSource, prov, srcProv)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFilesMergesLaterFileOverEarlier(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTestFile(t, dir, "app.json", `{"db":{"host":"base","port":5432},"name":"app"}`)
+	overlay := writeTestFile(t, dir, "app.dev.json", `{"db":{"host":"dev"}}`)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseFiles(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := cfg.String(context.Background(), "db::host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "dev" {
+		t.Fatalf("expected overlay to win for db.host, got %q", host)
+	}
+
+	port, err := cfg.Int(context.Background(), "db::port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 5432 {
+		t.Fatalf("expected base value to survive for db.port, got %d", port)
+	}
+}
+
+func TestParseFilesResolvesIncludeDirective(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "common.json", `{"log":{"level":"info"}}`)
+	main := writeTestFile(t, dir, "app.json", `{"include":"common.json","name":"app"}`)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseFiles(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	level, err := cfg.String(context.Background(), "log::level")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level != "info" {
+		t.Fatalf("expected included value \"info\", got %q", level)
+	}
+}
+
+func TestSourceResolvesArrayElementProvenance(t *testing.T) {
+	dir := t.TempDir()
+	base := writeTestFile(t, dir, "app.json", `{"servers":[{"port":5432}]}`)
+	overlay := writeTestFile(t, dir, "app.dev.json", `{"servers":[{"port":5433}]}`)
+
+	js := &JSONConfig{}
+	cfg, err := js.ParseFiles(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := cfg.(*JSONConfigContainer)
+
+	if got := container.Source("servers"); got != overlay {
+		t.Fatalf("expected Source(\"servers\") to name the overlaying file, got %q", got)
+	}
+	if got := container.Source("servers.0.port"); got != overlay {
+		t.Fatalf("expected Source(\"servers.0.port\") to resolve the same way GetByPath addresses it, got %q", got)
+	}
+}
+
+func TestParseFilesDiamondIncludeIsNotTreatedAsCircular(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "common.json", `{"log":{"level":"info"}}`)
+	dev := writeTestFile(t, dir, "app.dev.json", `{"include":"common.json","env":"dev"}`)
+	prod := writeTestFile(t, dir, "app.prod.json", `{"include":"common.json","env":"prod"}`)
+
+	js := &JSONConfig{}
+	if _, err := js.ParseFiles(dev, prod); err != nil {
+		t.Fatalf("expected two files sharing an include to merge cleanly, got error: %v", err)
+	}
+}
+
+func TestParseFilesDetectsGenuineIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	if err := ioutil.WriteFile(a, []byte(`{"include":"b.json"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte(`{"include":"a.json"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	js := &JSONConfig{}
+	if _, err := js.ParseFiles(a); err == nil {
+		t.Fatal("expected a genuine a.json -> b.json -> a.json cycle to be rejected")
+	}
+}
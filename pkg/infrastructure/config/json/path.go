@@ -0,0 +1,330 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSeparator is the delimiter between segments of a GetByPath /
+// SetByPath / DeleteByPath path, e.g. "notify.webhook.0.endpoint".
+const pathSeparator = "."
+
+// pathSegment is one "." separated component of a path, already classified
+// as a plain object key, an array index, or a `#(field==value)` predicate.
+type pathSegment struct {
+	raw       string
+	index     int  // valid when isIndex
+	isIndex   bool // raw parses as an integer; it is used as an index only against an array
+	predField string
+	predValue string
+	isPred    bool // raw was `#(field==value)`
+}
+
+// unquote strips a single matching pair of surrounding " or ' characters
+// from s, leaving s untouched if it isn't quoted. Predicate values are
+// commonly written quoted - "servers.#(name==\"db\").port" - to visually set
+// the literal apart from the field name either side of "=="; since the value
+// they're compared against during traversal is always an unquoted
+// stringified scalar, the quotes have to come off here rather than at match
+// time.
+func unquote(s string) string {
+	if len(s) >= 2 {
+		if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+// parsePath splits a path into segments. A segment is treated as an array
+// index only when the value it is being matched against at traversal time is
+// actually a []interface{} - this is what lets a literal object key such as
+// "0" (a stringified integer, e.g. a map keyed by numeric IDs) survive
+// instead of being silently reinterpreted as "element zero of an array".
+func parsePath(path string) []pathSegment {
+	parts := strings.Split(path, pathSeparator)
+	segments := make([]pathSegment, 0, len(parts))
+	for _, p := range parts {
+		seg := pathSegment{raw: p}
+		if strings.HasPrefix(p, "#(") && strings.HasSuffix(p, ")") {
+			inner := p[2 : len(p)-1]
+			if eq := strings.Index(inner, "=="); eq >= 0 {
+				seg.isPred = true
+				seg.predField = inner[:eq]
+				seg.predValue = unquote(inner[eq+2:])
+			}
+		} else if n, err := strconv.Atoi(p); err == nil {
+			seg.index = n
+			seg.isIndex = true
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// GetByPath resolves a gjson-style path against the container's data,
+// supporting plain object traversal ("a.b.c"), array indexing
+// ("servers.0.port") and predicate selection
+// ("servers.#(name==db).port"). A path segment is only treated as an array
+// index against an actual []interface{} value - against a map the same
+// segment addresses the object key with that literal name, so a map keyed
+// by stringified integers ("0", "1", ...) is never confused with an array.
+func (c *JSONConfigContainer) GetByPath(ctx context.Context, path string) (interface{}, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	v, err := getAtPath(c.data, parsePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("path %q: %w", path, err)
+	}
+	return v, nil
+}
+
+// getAtPath resolves segments against an arbitrary data tree, independent
+// of any particular container - used by SaveConfigFile to locate a value
+// inside the copy it is about to write out.
+func getAtPath(data map[string]interface{}, segments []pathSegment) (interface{}, error) {
+	cur := interface{}(data)
+	for _, seg := range segments {
+		next, err := resolveSegment(cur, seg)
+		if err != nil {
+			return nil, err
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+func resolveSegment(cur interface{}, seg pathSegment) (interface{}, error) {
+	switch v := cur.(type) {
+	case map[string]interface{}:
+		val, ok := v[seg.raw]
+		if !ok {
+			return nil, fmt.Errorf("key not found: %s", seg.raw)
+		}
+		return val, nil
+	case []interface{}:
+		if seg.isPred {
+			for _, elem := range v {
+				m, ok := elem.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if fmt.Sprintf("%v", m[seg.predField]) == seg.predValue {
+					return elem, nil
+				}
+			}
+			return nil, fmt.Errorf("no element matches %s==%s", seg.predField, seg.predValue)
+		}
+		if !seg.isIndex {
+			return nil, fmt.Errorf("%q is not a valid array index", seg.raw)
+		}
+		if seg.index < 0 || seg.index >= len(v) {
+			return nil, fmt.Errorf("index %d out of range", seg.index)
+		}
+		return v[seg.index], nil
+	default:
+		return nil, fmt.Errorf("cannot traverse into %T with %q", cur, seg.raw)
+	}
+}
+
+// SetByPath writes val at path, creating intermediate objects/arrays as
+// needed. Array growth only happens when a segment already resolves through
+// a []interface{}, or when it is the first segment written into a brand new
+// path whose parent container does not exist yet and the segment looks like
+// a plain non-negative integer; an existing object is never reinterpreted as
+// an array just because a numeric-looking key is set on it.
+func (c *JSONConfigContainer) SetByPath(ctx context.Context, path string, val interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+
+	segments := parsePath(path)
+	if len(segments) == 0 {
+		return errors.New("empty path")
+	}
+	if c.data == nil {
+		// See Set's matching nil check: a watched Sub() container can land
+		// here with a nil data map after a reload whose upstream document no
+		// longer has anything at subPrefix.
+		c.data = make(map[string]interface{})
+	}
+	return setSegment(&c.data, nil, segments, val)
+}
+
+// setSegment threads a pointer to the current container slot (root is
+// *map[string]interface{}, a nested array slot is *[]interface{}) through
+// the remaining path segments.
+func setSegment(rootMap *map[string]interface{}, rootSlice *[]interface{}, segments []pathSegment, val interface{}) error {
+	seg := segments[0]
+	last := len(segments) == 1
+
+	if rootMap != nil {
+		m := *rootMap
+		if seg.isPred {
+			return fmt.Errorf("predicate segment %q cannot address an object", seg.raw)
+		}
+		if last {
+			m[seg.raw] = val
+			return nil
+		}
+		child, ok := m[seg.raw]
+		if !ok {
+			// Decide the shape of the new container from the *next*
+			// segment, not from this one: this is what keeps a literal
+			// key like "0" from being promoted into an array just because
+			// it parses as a number.
+			if segments[1].isIndex && !segments[1].isPred {
+				child = make([]interface{}, 0)
+			} else {
+				child = make(map[string]interface{}, 0)
+			}
+			m[seg.raw] = child
+		}
+		return descend(m, seg.raw, child, segments[1:], val)
+	}
+
+	s := *rootSlice
+	idx, err := sliceTargetIndex(s, seg)
+	if err != nil {
+		return err
+	}
+	for idx >= len(s) {
+		s = append(s, nil)
+	}
+	*rootSlice = s
+	if last {
+		s[idx] = val
+		return nil
+	}
+	child := s[idx]
+	if child == nil {
+		if segments[1].isIndex && !segments[1].isPred {
+			child = make([]interface{}, 0)
+		} else {
+			child = make(map[string]interface{}, 0)
+		}
+		s[idx] = child
+	}
+	return descendSlice(s, idx, child, segments[1:], val)
+}
+
+func sliceTargetIndex(s []interface{}, seg pathSegment) (int, error) {
+	if seg.isPred {
+		for i, elem := range s {
+			m, ok := elem.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if fmt.Sprintf("%v", m[seg.predField]) == seg.predValue {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no element matches %s==%s", seg.predField, seg.predValue)
+	}
+	if !seg.isIndex || seg.index < 0 {
+		return 0, fmt.Errorf("%q is not a valid array index", seg.raw)
+	}
+	return seg.index, nil
+}
+
+func descend(parent map[string]interface{}, key string, child interface{}, rest []pathSegment, val interface{}) error {
+	switch c := child.(type) {
+	case map[string]interface{}:
+		if err := setSegment(&c, nil, rest, val); err != nil {
+			return err
+		}
+		parent[key] = c
+		return nil
+	case []interface{}:
+		if err := setSegment(nil, &c, rest, val); err != nil {
+			return err
+		}
+		parent[key] = c
+		return nil
+	default:
+		return fmt.Errorf("cannot descend into %T at %q", child, key)
+	}
+}
+
+func descendSlice(parent []interface{}, idx int, child interface{}, rest []pathSegment, val interface{}) error {
+	switch c := child.(type) {
+	case map[string]interface{}:
+		if err := setSegment(&c, nil, rest, val); err != nil {
+			return err
+		}
+		parent[idx] = c
+		return nil
+	case []interface{}:
+		if err := setSegment(nil, &c, rest, val); err != nil {
+			return err
+		}
+		parent[idx] = c
+		return nil
+	default:
+		return fmt.Errorf("cannot descend into %T at index %d", child, idx)
+	}
+}
+
+// DeleteByPath removes the value at path. Deleting an array element
+// compacts the array, matching sjson's delete semantics.
+func (c *JSONConfigContainer) DeleteByPath(ctx context.Context, path string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	segments := parsePath(path)
+	if len(segments) == 0 {
+		return errors.New("empty path")
+	}
+	parentPath := segments[:len(segments)-1]
+	last := segments[len(segments)-1]
+
+	cur := interface{}(c.data)
+	for _, seg := range parentPath {
+		next, err := resolveSegment(cur, seg)
+		if err != nil {
+			return fmt.Errorf("path %q: %w", path, err)
+		}
+		cur = next
+	}
+
+	switch v := cur.(type) {
+	case map[string]interface{}:
+		if last.isPred {
+			return fmt.Errorf("predicate segment %q cannot address an object", last.raw)
+		}
+		delete(v, last.raw)
+		return nil
+	case []interface{}:
+		idx, err := sliceTargetIndex(v, last)
+		if err != nil {
+			return err
+		}
+		if idx < 0 || idx >= len(v) {
+			return fmt.Errorf("index %d out of range", idx)
+		}
+		// Compact in place. Since []interface{} is value-copied on every
+		// descend, the trimmed slice has to be written back explicitly
+		// rather than mutated through the already-resolved cur.
+		trimmed := append(v[:idx], v[idx+1:]...)
+		return setSegment(&c.data, nil, parentPath, trimmed)
+	default:
+		return fmt.Errorf("cannot delete from %T", cur)
+	}
+}
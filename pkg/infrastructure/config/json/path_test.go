@@ -0,0 +1,113 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetByPathPredicateAcceptsQuotedValue(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"servers":[{"name":"db","port":5432},{"name":"web","port":80}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	got, err := c.GetByPath(context.Background(), `servers.#(name=="db").port`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.(float64) != 5432 {
+		t.Fatalf("expected 5432, got %v", got)
+	}
+
+	got, err = c.GetByPath(context.Background(), "servers.#(name==web).port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.(float64) != 80 {
+		t.Fatalf("expected 80, got %v", got)
+	}
+}
+
+func TestGetByPathArrayIndexVsLiteralKey(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"servers":["a","b"],"ids":{"0":"zero","1":"one"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	got, err := c.GetByPath(context.Background(), "servers.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "b" {
+		t.Fatalf("expected array element \"b\", got %v", got)
+	}
+
+	got, err = c.GetByPath(context.Background(), "ids.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "one" {
+		t.Fatalf("expected map key \"1\" to resolve to \"one\", got %v", got)
+	}
+}
+
+func TestSetByPathCreatesIntermediateContainers(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	if err := c.SetByPath(context.Background(), "notify.webhook.0.endpoint", "https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetByPath(context.Background(), "notify.webhook.0.endpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com" {
+		t.Fatalf("expected set value to round-trip, got %v", got)
+	}
+}
+
+func TestDeleteByPathCompactsArray(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"servers":["a","b","c"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	if err := c.DeleteByPath(context.Background(), "servers.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetByPath(context.Background(), "servers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := got.([]interface{})
+	if len(arr) != 2 || arr[0] != "a" || arr[1] != "c" {
+		t.Fatalf("expected [\"a\",\"c\"] after deleting index 1, got %v", arr)
+	}
+}
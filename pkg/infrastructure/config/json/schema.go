@@ -0,0 +1,213 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// schemaRegistry holds the draft-07 JSON Schemas registered per prefix via
+// JSONConfig.RegisterSchema. It is created by the first RegisterSchema call
+// and shared, by pointer, with every JSONConfigContainer the JSONConfig
+// parses from then on, so a later schema is also honored by those
+// containers. A container parsed before the first RegisterSchema call holds
+// no registry and is never validated.
+type schemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]*gojsonschema.Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*gojsonschema.Schema)}
+}
+
+// RegisterSchema validates, at registration time, that schema itself is
+// well-formed, then stores it so every future Unmarshaler(ctx, prefix, ...)
+// call on this JSONConfig's containers is checked against it before
+// decoding, except on containers parsed before the first RegisterSchema
+// call (see schemaRegistry). Passing an empty prefix validates the whole
+// document.
+func (js *JSONConfig) RegisterSchema(prefix string, schema []byte) error {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return fmt.Errorf("invalid json schema for prefix %q: %w", prefix, err)
+	}
+
+	if js.schemas == nil {
+		js.schemas = newSchemaRegistry()
+	}
+	js.schemas.mu.Lock()
+	defer js.schemas.mu.Unlock()
+	js.schemas.schemas[prefix] = compiled
+	return nil
+}
+
+func (r *schemaRegistry) get(prefix string) (*gojsonschema.Schema, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[prefix]
+	return s, ok
+}
+
+// validate runs the schema registered for prefix, if any, against data and
+// turns the first failing assertion into a path-qualified error built from
+// gojsonschema's field path (relative to data) and description, such as
+// `servers.2.port: Must be greater than or equal to 1`.
+func (r *schemaRegistry) validate(prefix string, data map[string]interface{}) error {
+	schema, ok := r.get(prefix)
+	if !ok {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(data))
+	if err != nil {
+		return fmt.Errorf("schema validation for %q failed: %w", prefix, err)
+	}
+	if !result.Valid() {
+		errs := result.Errors()
+		return fmt.Errorf("%s: %s", errs[0].Field(), errs[0].Description())
+	}
+	return nil
+}
+
+// applyStructTags walks obj - a pointer to the struct being decoded into -
+// and, for every field tagged with `default`, `required` or `validate`,
+// fills in missing values or returns a precise, path-qualified error before
+// mapstructure ever runs. data is mutated in place so the defaults it
+// injects are picked up by the subsequent mapstructure.Decode.
+func applyStructTags(obj interface{}, data map[string]interface{}, path string) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Elem().Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+
+		key := field.Name
+		if tag := field.Tag.Get("mapstructure"); tag != "" {
+			key = strings.Split(tag, ",")[0]
+		}
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+
+		raw, present := lookupFold(data, key)
+
+		if !present {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				data[key] = def
+				raw, present = def, true
+			} else if field.Tag.Get("required") == "true" {
+				return fmt.Errorf("%s: required field is missing", fieldPath)
+			}
+		}
+
+		if present {
+			if err := checkValidateTag(fieldPath, field.Tag.Get("validate"), raw); err != nil {
+				return err
+			}
+		}
+
+		// Recurse into nested struct fields so a nested field can still
+		// fail with a precise dotted path such as "db.pool.size". Slices
+		// and maps of structs are not walked.
+		if field.Type.Kind() == reflect.Struct {
+			if sub, ok := raw.(map[string]interface{}); ok {
+				if err := applyStructTags(rv.Elem().Field(i).Addr().Interface(), sub, fieldPath); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lookupFold looks up key in data, falling back to a case-insensitive match
+// so a `Port` field tagged only by Go convention still finds a `port` key.
+func lookupFold(data map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := data[key]; ok {
+		return v, true
+	}
+	for k, v := range data {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// checkValidateTag evaluates a `validate:"min=1,max=65535"` style tag
+// against a scalar value.
+func checkValidateTag(fieldPath, tag string, value interface{}) error {
+	if tag == "" {
+		return nil
+	}
+
+	num, isNum := toFloat64(value)
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		bound, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || !isNum {
+			continue
+		}
+		switch kv[0] {
+		case "min":
+			if num < bound {
+				return fmt.Errorf("%s: must be >= %v", fieldPath, bound)
+			}
+		case "max":
+			if num > bound {
+				return fmt.Errorf("%s: must be <= %v", fieldPath, bound)
+			}
+		}
+	}
+	return nil
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
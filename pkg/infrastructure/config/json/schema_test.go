@@ -0,0 +1,133 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type schemaTestTarget struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+}
+
+func TestUnmarshalerRejectsValueFailingRegisteredSchema(t *testing.T) {
+	js := &JSONConfig{}
+	if err := js.RegisterSchema("db", []byte(`{
+		"type": "object",
+		"properties": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}},
+		"required": ["host", "port"]
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"localhost","port":999999}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target schemaTestTarget
+	err = cfg.Unmarshaler(context.Background(), "db", &target)
+	if err == nil {
+		t.Fatal("expected schema validation error for out-of-range port")
+	}
+}
+
+func TestUnmarshalerAcceptsValuePassingRegisteredSchema(t *testing.T) {
+	js := &JSONConfig{}
+	if err := js.RegisterSchema("db", []byte(`{
+		"type": "object",
+		"properties": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}},
+		"required": ["host", "port"]
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"localhost","port":5432}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target schemaTestTarget
+	if err := cfg.Unmarshaler(context.Background(), "db", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Host != "localhost" || target.Port != 5432 {
+		t.Fatalf("unexpected decode result: %+v", target)
+	}
+}
+
+type tagTestTarget struct {
+	Host string `mapstructure:"host" required:"true"`
+	Port int    `mapstructure:"port" default:"8080" validate:"min=1,max=65535"`
+}
+
+func TestApplyStructTagsFillsDefaultAndEnforcesRequired(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"svc":{"host":"localhost"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target tagTestTarget
+	if err := cfg.Unmarshaler(context.Background(), "svc", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Port != 8080 {
+		t.Fatalf("expected default port 8080, got %d", target.Port)
+	}
+
+	cfg, err = js.ParseData([]byte(`{"svc":{"port":80}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing tagTestTarget
+	err = cfg.Unmarshaler(context.Background(), "svc", &missing)
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Fatalf("expected a required-field error, got %v", err)
+	}
+}
+
+type subSchemaTarget struct {
+	Port int `mapstructure:"port"`
+}
+
+func TestSubUnmarshalerValidatesAgainstRootRelativeSchema(t *testing.T) {
+	js := &JSONConfig{}
+	if err := js.RegisterSchema("db", []byte(`{
+		"type": "object",
+		"properties": {"port": {"type": "integer", "maximum": 65535}}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := js.ParseData([]byte(`{"db":{"port":999999}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := cfg.Sub(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target subSchemaTarget
+	err = sub.Unmarshaler(context.Background(), "", &target)
+	if err == nil {
+		t.Fatal("expected the schema registered for \"db\" to still apply through Sub(\"db\").Unmarshaler(\"\", ...)")
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/astaxie/beego/pkg/infrastructure/logs"
+)
+
+// debounceWindow is how long the file watcher waits after the last detected
+// write before it re-parses the file. Editors and deploy tooling frequently
+// emit several events (truncate, write, chmod, rename-into-place) for a
+// single logical save, so we coalesce them instead of reloading on every one.
+const debounceWindow = 300 * time.Millisecond
+
+// RemoteProvider is implemented by remote configuration backends (etcd,
+// consul, a plain HTTP endpoint, ...) that a JSONConfigContainer can poll
+// for changes in addition to, or instead of, watching a local file.
+type RemoteProvider interface {
+	// Fetch returns the latest raw JSON document from the remote source.
+	Fetch(ctx context.Context) ([]byte, error)
+}
+
+// watchState holds everything needed to keep a JSONConfigContainer
+// live-reloadable. It is created lazily the first time OnChange, Register or
+// WatchRemote is called on a container. The background watchers it starts
+// keep running until StopWatching closes fileStopCh and remoteStopCh; they
+// are not stopped automatically.
+type watchState struct {
+	mu sync.Mutex
+
+	filename string
+	provider RemoteProvider
+	interval time.Duration
+
+	nextID    int
+	callbacks map[string]map[string]func(value string)
+
+	fileStopCh   chan struct{}
+	remoteStopCh chan struct{}
+}
+
+// normalizeChangeKey converts key's "section::name" Get/DIY-style
+// addressing into the "."-joined form flatten() builds from a reloaded
+// document, so a callback registered as e.g. "db::host" is compared
+// against the same "db.host" path reloadFromBytes diffs on. A key that is
+// already dotted (or empty, for the catch-all subscription) passes
+// through unchanged.
+func normalizeChangeKey(key string) string {
+	return strings.ReplaceAll(key, "::", ".")
+}
+
+// OnChange registers fn to be invoked whenever the value at key changes
+// following a reload of the underlying file or remote source. key uses the
+// same "section::name" addressing as Get/DIY (a dotted path also works,
+// since both are normalized to the same form internally); an empty key
+// subscribes to changes anywhere in the document. The first call to
+// OnChange on a container lazily starts the appropriate background
+// watcher (fsnotify for file-backed containers, polling for containers
+// attached to a RemoteProvider via WatchRemote).
+func (c *JSONConfigContainer) OnChange(ctx context.Context, key string, fn func(value string)) {
+	c.Register(ctx, key, fn)
+}
+
+// Register behaves like OnChange but returns a subscription id that can
+// later be passed to Unregister to stop receiving callbacks for key.
+func (c *JSONConfigContainer) Register(ctx context.Context, key string, fn func(value string)) string {
+	ws := c.ensureWatchState()
+	key = normalizeChangeKey(key)
+
+	ws.mu.Lock()
+	ws.nextID++
+	id := strconv.Itoa(ws.nextID)
+	if ws.callbacks[key] == nil {
+		ws.callbacks[key] = make(map[string]func(value string))
+	}
+	ws.callbacks[key][id] = fn
+	ws.mu.Unlock()
+
+	ws.start(c)
+
+	return id
+}
+
+// Unregister removes a previously registered callback for key. id is the
+// value returned by Register; it is a no-op if the subscription no longer
+// exists.
+func (c *JSONConfigContainer) Unregister(ctx context.Context, key, id string) {
+	c.RLock()
+	ws := c.watch
+	c.RUnlock()
+	if ws == nil {
+		return
+	}
+	key = normalizeChangeKey(key)
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	delete(ws.callbacks[key], id)
+	if len(ws.callbacks[key]) == 0 {
+		delete(ws.callbacks, key)
+	}
+}
+
+// WatchRemote attaches a RemoteProvider to the container and starts polling
+// it for changes every interval, in the same way a local file is watched.
+// It is modeled after the reload loop config-management clients (etcd,
+// consul, HTTP long-poll) use to keep a cached document fresh.
+func (c *JSONConfigContainer) WatchRemote(ctx context.Context, provider RemoteProvider, interval time.Duration) error {
+	if provider == nil {
+		return fmt.Errorf("nil remote provider")
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ws := c.ensureWatchState()
+	ws.mu.Lock()
+	ws.provider = provider
+	ws.interval = interval
+	ws.mu.Unlock()
+
+	ws.start(c)
+	return nil
+}
+
+// StopWatching stops any background file or remote watcher started for this
+// container. It is safe to call on a container that was never watched.
+func (c *JSONConfigContainer) StopWatching() {
+	c.RLock()
+	ws := c.watch
+	c.RUnlock()
+	if ws == nil {
+		return
+	}
+	ws.mu.Lock()
+	fileStopCh := ws.fileStopCh
+	remoteStopCh := ws.remoteStopCh
+	ws.fileStopCh = nil
+	ws.remoteStopCh = nil
+	ws.mu.Unlock()
+	if fileStopCh != nil {
+		close(fileStopCh)
+	}
+	if remoteStopCh != nil {
+		close(remoteStopCh)
+	}
+}
+
+func (c *JSONConfigContainer) ensureWatchState() *watchState {
+	c.Lock()
+	defer c.Unlock()
+	if c.watch == nil {
+		c.watch = &watchState{
+			filename:  c.filename,
+			callbacks: make(map[string]map[string]func(value string)),
+		}
+	}
+	return c.watch
+}
+
+// start launches whichever of the file watcher / remote poller isn't
+// already running. The two are tracked independently so that, say, calling
+// WatchRemote on a container that already has a file watcher (started by
+// an earlier OnChange) still starts the poller instead of being treated as
+// a no-op.
+func (ws *watchState) start(c *JSONConfigContainer) {
+	ws.mu.Lock()
+	filename := ws.filename
+	provider := ws.provider
+	interval := ws.interval
+
+	var fileStopCh chan struct{}
+	startFile := filename != "" && ws.fileStopCh == nil
+	if startFile {
+		fileStopCh = make(chan struct{})
+		ws.fileStopCh = fileStopCh
+	}
+
+	var remoteStopCh chan struct{}
+	startRemote := provider != nil && ws.remoteStopCh == nil
+	if startRemote {
+		remoteStopCh = make(chan struct{})
+		ws.remoteStopCh = remoteStopCh
+	}
+	ws.mu.Unlock()
+
+	if startFile {
+		go watchFile(c, ws, filename, fileStopCh)
+	}
+	if startRemote {
+		go pollRemote(c, ws, provider, interval, remoteStopCh)
+	}
+}
+
+func watchFile(c *JSONConfigContainer, ws *watchState, filename string, stopCh chan struct{}) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		logs.Warn("json config: failed to start file watcher for %s: %v", filename, err)
+		return
+	}
+	defer w.Close()
+
+	// Watch the containing directory rather than the file itself: editors
+	// and config-management agents commonly save by writing a temp file and
+	// renaming it over the original, which replaces the inode fsnotify was
+	// watching.
+	dir := filepath.Dir(filename)
+	if err := w.Add(dir); err != nil {
+		logs.Warn("json config: failed to watch directory %s: %v", dir, err)
+		return
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case <-stopCh:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != filepath.Clean(filename) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(debounceWindow, func() { reload(c, ws, filename) })
+			} else {
+				timer.Reset(debounceWindow)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logs.Warn("json config: watcher error for %s: %v", filename, err)
+		}
+	}
+}
+
+func pollRemote(c *JSONConfigContainer, ws *watchState, provider RemoteProvider, interval time.Duration, stopCh chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			data, err := provider.Fetch(ctx)
+			cancel()
+			if err != nil {
+				logs.Warn("json config: remote provider fetch failed: %v", err)
+				continue
+			}
+			reloadFromBytes(c, ws, data)
+		}
+	}
+}
+
+// flatten walks data and writes a "." joined path for every scalar leaf into
+// out, stringifying the leaf value. It is used to diff two successive
+// snapshots of a container's data so OnChange callbacks fire only for keys
+// whose value actually changed.
+func flatten(prefix string, data interface{}, out map[string]string) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, val, out)
+		}
+	case []interface{}:
+		for i, val := range v {
+			key := fmt.Sprintf("%s.%d", prefix, i)
+			flatten(key, val, out)
+		}
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprintf("%v", v)
+	}
+}
+
+// subtreeAt walks doc following the dotted prefix Sub recorded and returns
+// the map found there, or nil if the reloaded document no longer has
+// anything at that path. It only descends through objects, matching the
+// plain-key addressing Sub itself uses (not the full gjson path syntax).
+func subtreeAt(doc map[string]interface{}, prefix string) map[string]interface{} {
+	cur := map[string]interface{}(doc)
+	for _, part := range strings.Split(prefix, pathSeparator) {
+		next, ok := cur[part]
+		if !ok {
+			return nil
+		}
+		m, ok := next.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m
+	}
+	return cur
+}
+
+// reload re-reads filename from disk and applies it atomically.
+func reload(c *JSONConfigContainer, ws *watchState, filename string) {
+	content, err := readFile(filename)
+	if err != nil {
+		logs.Warn("json config: reload of %s failed: %v", filename, err)
+		return
+	}
+	reloadFromBytes(c, ws, content)
+}
+
+// reloadFromBytes parses data, swaps it into the container atomically and
+// fires callbacks for every flattened key whose value changed.
+func reloadFromBytes(c *JSONConfigContainer, ws *watchState, data []byte) {
+	parsed := make(map[string]interface{})
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		logs.Warn("json config: reload produced invalid json: %v", err)
+		return
+	}
+	parsed = flattenedEnv(parsed)
+
+	c.Lock()
+	old := c.data
+	scoped := parsed
+	if c.subPrefix != "" {
+		scoped = subtreeAt(parsed, c.subPrefix)
+	}
+	c.data = scoped
+	c.Unlock()
+
+	oldFlat := make(map[string]string)
+	flatten("", old, oldFlat)
+	newFlat := make(map[string]string)
+	flatten("", scoped, newFlat)
+
+	changed := make(map[string]string)
+	for k, v := range newFlat {
+		if oldFlat[k] != v {
+			changed[k] = v
+		}
+	}
+	for k := range oldFlat {
+		if _, ok := newFlat[k]; !ok {
+			changed[k] = ""
+		}
+	}
+
+	ws.mu.Lock()
+	callbacks := ws.callbacks
+	ws.mu.Unlock()
+
+	// Callbacks must never run while the container's RWMutex is held, since
+	// a callback re-entering Get/Set on this same container is an expected
+	// usage pattern.
+	for key, fns := range callbacks {
+		value, isChanged := changed[key]
+		if key != "" && !isChanged {
+			continue
+		}
+		if key == "" {
+			if len(changed) == 0 {
+				continue
+			}
+			// Subscribers on the empty key want to know that *something*
+			// changed; hand them the new document for this container's own
+			// scope (the whole document for a root container, just the
+			// subtree for one returned by Sub).
+			if raw, err := json.Marshal(scoped); err == nil {
+				value = string(raw)
+			}
+		}
+		for _, fn := range fns {
+			fn(value)
+		}
+	}
+}
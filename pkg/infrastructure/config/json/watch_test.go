@@ -0,0 +1,120 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeRemoteProvider struct{}
+
+func (fakeRemoteProvider) Fetch(ctx context.Context) ([]byte, error) {
+	return []byte(`{}`), nil
+}
+
+func TestOnChangeFiresOnlyForChangedKey(t *testing.T) {
+	js := &JSONConfig{}
+	cfg, err := js.ParseData([]byte(`{"db":{"host":"a","port":5432},"other":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+
+	var gotHost string
+	hostFired := false
+	c.OnChange(context.Background(), "db::host", func(value string) {
+		hostFired = true
+		gotHost = value
+	})
+
+	otherFired := false
+	c.OnChange(context.Background(), "other", func(value string) {
+		otherFired = true
+	})
+
+	c.RLock()
+	ws := c.watch
+	c.RUnlock()
+
+	reloadFromBytes(c, ws, []byte(`{"db":{"host":"b","port":5432},"other":"x"}`))
+
+	if !hostFired {
+		t.Fatalf("expected db::host subscriber to fire when db.host changed")
+	}
+	if gotHost != "b" {
+		t.Fatalf("expected callback value %q, got %q", "b", gotHost)
+	}
+	if otherFired {
+		t.Fatalf("did not expect the unchanged \"other\" subscriber to fire")
+	}
+}
+
+func TestWatchRemoteStartsEvenWhenFileWatchAlreadyRunning(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.json")
+	if err := ioutil.WriteFile(file, []byte(`{"a":"b"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	js := &JSONConfig{}
+	cfg, err := js.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.(*JSONConfigContainer)
+	defer c.StopWatching()
+
+	c.OnChange(context.Background(), "", func(string) {})
+
+	c.RLock()
+	ws := c.watch
+	c.RUnlock()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		ws.mu.Lock()
+		started := ws.fileStopCh != nil
+		ws.mu.Unlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected file watcher to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := c.WatchRemote(context.Background(), fakeRemoteProvider{}, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline = time.Now().Add(time.Second)
+	for {
+		ws.mu.Lock()
+		started := ws.remoteStopCh != nil
+		ws.mu.Unlock()
+		if started {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected remote poller to start even though the file watcher was already running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}